@@ -0,0 +1,141 @@
+// Demonstrates carrying gRPC in both directions over a single bon connection.
+// Both peers run a grpc.Server and call each other's health check RPC over
+// the same yamux session.
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/ilgooz/bon"
+	"github.com/ilgooz/bon/bongrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type service struct {
+	addr    string
+	session *yamux.Session
+}
+
+func newService(addr string) *service {
+	return &service{
+		addr: addr,
+	}
+}
+
+func (s *service) Accept() (net.Conn, error) {
+	return s.session.Accept()
+}
+
+func (s *service) Open(r bon.Route) (net.Conn, error) {
+	return s.session.Open()
+}
+
+func (s *service) Close() error {
+	return s.session.Close()
+}
+
+func (s *service) runYamuxServer() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	s.session, err = yamux.Server(conn, nil)
+	return err
+}
+
+func (s *service) runYamuxClient() error {
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.session, err = yamux.Client(conn, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+const (
+	// HealthRPC is the Route each peer mounts its health check grpc.Server on.
+	HealthRPC bon.Route = 1 << iota
+)
+
+// runPeer mounts a health server on HealthRPC and calls the other peer's
+// health check over the same bon connection.
+func runPeer(name string, b *bon.Bon) {
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(bongrpc.Listener(b, HealthRPC))
+
+	conn, err := grpc.Dial("bon", bongrpc.DialOption(b, HealthRPC), grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s got health status from peer: %s", name, resp.Status)
+}
+
+func main() {
+	addr := ":3201"
+	service1 := newService(addr)
+	service2 := newService(addr)
+
+	// start yamux server and client.
+	go func() {
+		if err := service1.runYamuxServer(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		if err := service2.runYamuxClient(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// wait for connections to get ready.
+	time.Sleep(time.Millisecond * 100)
+
+	bon1 := bon.New(service1)
+	go bon1.Run()
+
+	bon2 := bon.New(service2)
+	go bon2.Run()
+
+	var done = make(chan struct{})
+	go func() {
+		runPeer("bon1", bon1)
+		done <- struct{}{}
+	}()
+	go func() {
+		runPeer("bon2", bon2)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	if err := bon1.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := bon2.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
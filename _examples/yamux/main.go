@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -91,24 +92,24 @@ func main() {
 
 	// register handlers for bon1 and start accepting connections.
 	bon1 := bon.New(service1)
-	bon1.Handle(GRPCConn, func(conn net.Conn) {
+	bon1.Handle(GRPCConn, bon.HandlerFunc(func(ctx context.Context, conn net.Conn, md bon.Metadata) {
 		_, err := conn.Write([]byte("grpc"))
 		if err != nil {
 			log.Fatal(err)
 		}
 		conn.Close()
-	})
+	}))
 	go bon1.Run()
 
 	// register handlers for bon2 and start accepting connections.
 	bon2 := bon.New(service2)
-	bon2.Handle(ChatConn, func(conn net.Conn) {
+	bon2.Handle(ChatConn, bon.HandlerFunc(func(ctx context.Context, conn net.Conn, md bon.Metadata) {
 		_, err := conn.Write([]byte("chat"))
 		if err != nil {
 			log.Fatal(err)
 		}
 		conn.Close()
-	})
+	}))
 	go bon2.Run()
 
 	// read handler response from bon2
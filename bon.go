@@ -4,7 +4,10 @@
 package bon
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/gob"
 	"fmt"
 	"io"
 	"log"
@@ -16,6 +19,52 @@ import (
 // Route provides type safety when you describe your connection routes for handlers.
 type Route uint32
 
+// Metadata carries optional out-of-band key/value pairs alongside a routed
+// connection, e.g. a deadline, an auth token, or a trace id, so middleware
+// registered with Use can act on them without every handler re-implementing
+// the same parsing. It's only delivered when HandshakeFramed is negotiated;
+// ConnectWithMetadata called over HandshakeLegacy silently drops md.
+type Metadata map[string]string
+
+// Handler serves a single net.Conn accepted for a Route. ctx is canceled once
+// the connection is done being served; md is whatever Metadata the peer
+// attached via ConnectWithMetadata.
+type Handler interface {
+	ServeConn(ctx context.Context, conn net.Conn, md Metadata)
+}
+
+// HandlerFunc adapts a plain function to a Handler.
+type HandlerFunc func(ctx context.Context, conn net.Conn, md Metadata)
+
+// ServeConn calls f.
+func (f HandlerFunc) ServeConn(ctx context.Context, conn net.Conn, md Metadata) {
+	f(ctx, conn, md)
+}
+
+// Middleware wraps a Handler to add cross-cutting behavior, e.g. logging,
+// auth, tracing, or rate-limiting, without every handler implementing it
+// itself. See Bon.Use.
+type Middleware func(next Handler) Handler
+
+// HandshakeVersion selects the wire format Connect uses to tell the receiver
+// which Route a new connection is for. Both ends of a connection must agree
+// on it via HandshakeVersionOption; it isn't negotiated live on the wire.
+type HandshakeVersion byte
+
+const (
+	// HandshakeLegacy is the original bare 4-byte big-endian Route
+	// handshake, byte-for-byte what bon spoke before HandshakeVersion
+	// existed: no version prefix, no Metadata support. Use it while
+	// rolling HandshakeFramed out to a fleet one peer at a time, so
+	// peers still on the old code can keep parsing the connections the
+	// upgraded ones open.
+	HandshakeLegacy HandshakeVersion = 0
+
+	// HandshakeFramed is the current handshake: a 1-byte version prefix,
+	// the Route, and an optional gob-encoded Metadata blob.
+	HandshakeFramed HandshakeVersion = 1
+)
+
 // Transporter describes how your connection provider should be. Since it has both
 // Open and Accept methods your provider must behave like a Client and a Server
 // at the same time.
@@ -42,17 +91,23 @@ type Bon struct {
 	// transporter holds Transporter
 	transporter Transporter
 
-	handlers map[Route]func(net.Conn)
+	handlers map[Route]Handler
 	hm       sync.RWMutex
 
-	nonMatchingHandler func(net.Conn)
+	nonMatchingHandler Handler
 	nhm                sync.RWMutex
 
+	middlewares []Middleware
+	mwm         sync.RWMutex
+
 	// options keeps user options for Bon
 	options *opts
 
 	// log
 	log *log.Logger
+
+	brokerOnce sync.Once
+	broker     *Broker
 }
 
 // New expects a Transporter as a net.Conn provider. Since it just cares about net.Conn's,
@@ -62,9 +117,10 @@ type Bon struct {
 func New(t Transporter, options ...Option) *Bon {
 	b := &Bon{
 		transporter: t,
-		handlers:    make(map[Route]func(net.Conn)),
+		handlers:    make(map[Route]Handler),
 		options: &opts{
-			logOutput: os.Stdout,
+			logOutput:        os.Stdout,
+			handshakeVersion: HandshakeFramed,
 		},
 	}
 	for _, optionFunc := range options {
@@ -78,7 +134,8 @@ func New(t Transporter, options ...Option) *Bon {
 type Option func(*Bon)
 
 type opts struct {
-	logOutput io.Writer
+	logOutput        io.Writer
+	handshakeVersion HandshakeVersion
 }
 
 // LogOutputOption uses out as a log destination.
@@ -88,9 +145,18 @@ func LogOutputOption(out io.Writer) Option {
 	}
 }
 
+// HandshakeVersionOption selects which wire handshake Connect uses to
+// describe the requested Route. Defaults to HandshakeFramed; set it to
+// HandshakeLegacy only while some peers in the fleet haven't upgraded yet.
+func HandshakeVersionOption(v HandshakeVersion) Option {
+	return func(b *Bon) {
+		b.options.handshakeVersion = v
+	}
+}
+
 // Handle will handle connections for provided r. If there is no matching handlers and
 // HandleNonMatching not set, the sender will have a HandlerError.
-func (b *Bon) Handle(r Route, h func(net.Conn)) {
+func (b *Bon) Handle(r Route, h Handler) {
 	b.hm.Lock()
 	defer b.hm.Unlock()
 	b.handlers[r] = h
@@ -98,12 +164,32 @@ func (b *Bon) Handle(r Route, h func(net.Conn)) {
 
 // HandleNonMatching will handle connections that doesn't match any route. If you don't register
 // a handler here, the sender will have a HandlerError.
-func (b *Bon) HandleNonMatching(h func(net.Conn)) {
+func (b *Bon) HandleNonMatching(h Handler) {
 	b.nhm.Lock()
 	defer b.nhm.Unlock()
 	b.nonMatchingHandler = h
 }
 
+// Use appends mw to b's middleware chain. Middlewares wrap every Handler b
+// dispatches to, in the order they were registered with Use, so the first
+// Use call is the outermost and runs first. Call Use before Run so it
+// applies to every connection.
+func (b *Bon) Use(mw Middleware) {
+	b.mwm.Lock()
+	defer b.mwm.Unlock()
+	b.middlewares = append(b.middlewares, mw)
+}
+
+// chain wraps h with b's middlewares, outermost first.
+func (b *Bon) chain(h Handler) Handler {
+	b.mwm.RLock()
+	defer b.mwm.RUnlock()
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		h = b.middlewares[i](h)
+	}
+	return h
+}
+
 // Off will remove the registered handler for r.
 func (b *Bon) Off(r Route) {
 	b.hm.Lock()
@@ -118,6 +204,18 @@ func (b *Bon) OffNonMatching() {
 	b.nonMatchingHandler = nil
 }
 
+// Broker returns the Broker for b, creating it on first call. The Broker lets
+// both sides of the session rendezvous on transient, negotiated Route ids
+// instead of only on statically registered handlers; see Broker for details.
+//
+// Call Broker before Run, since it registers a reserved Route on b.
+func (b *Bon) Broker() *Broker {
+	b.brokerOnce.Do(func() {
+		b.broker = newBroker(b)
+	})
+	return b.broker
+}
+
 const (
 	handlerExists uint32 = 1 << iota
 	nonMatchingHandlerExists
@@ -127,14 +225,21 @@ const (
 // Connect opens a new connection for given route. If there is no handler for r at the
 // receiver's end an error will be returned.
 func (b *Bon) Connect(r Route) (net.Conn, error) {
+	return b.ConnectWithMetadata(r, nil)
+}
+
+// ConnectWithMetadata is like Connect, but also hands md to the receiver's
+// Handler. md is silently dropped when b was configured with
+// HandshakeVersionOption(HandshakeLegacy).
+func (b *Bon) ConnectWithMetadata(r Route, md Metadata) (net.Conn, error) {
 	conn, err := b.transporter.Open(r)
 	if err != nil {
 		return nil, err
 	}
 
-	// tell receiver which handler we want to use
-	err = b.writeUInt32(conn, uint32(r))
-	if err != nil {
+	// tell receiver which handler we want to use, and under which version
+	// of the handshake to interpret the rest of the header.
+	if err := b.writeHeader(conn, r, md); err != nil {
 		return nil, err
 	}
 
@@ -176,8 +281,8 @@ func (b *Bon) Close() error {
 //
 // It will silently die if the connection gets broken before invoking the handler.
 func (b *Bon) handleConn(conn net.Conn) {
-	// get Route id of requested handler
-	data, err := b.readUInt32(conn)
+	// get Route id and Metadata of requested handler
+	r, md, err := b.readHeader(conn)
 	if err != nil {
 		b.log.Println(err)
 		return
@@ -185,7 +290,7 @@ func (b *Bon) handleConn(conn net.Conn) {
 
 	handlerExistence := handlerDoesNotExists
 	b.hm.RLock()
-	h := b.handlers[Route(data)]
+	h := b.handlers[r]
 	b.hm.RUnlock()
 
 	if h != nil {
@@ -197,7 +302,6 @@ func (b *Bon) handleConn(conn net.Conn) {
 
 		if h != nil {
 			handlerExistence = nonMatchingHandlerExists
-			h = b.nonMatchingHandler
 		}
 	}
 
@@ -209,7 +313,9 @@ func (b *Bon) handleConn(conn net.Conn) {
 	}
 
 	if h != nil {
-		h(conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b.chain(h).ServeConn(ctx, conn, md)
 	} else {
 		err := conn.Close()
 		if err != nil {
@@ -218,6 +324,94 @@ func (b *Bon) handleConn(conn net.Conn) {
 	}
 }
 
+// writeHeader writes the handshake header for r (and md, when b negotiates
+// HandshakeFramed) to conn. HandshakeLegacy writes the bare 4-byte Route
+// baseline bon spoke, with no version prefix at all, so it interoperates
+// with peers that predate HandshakeVersion; only HandshakeFramed prefixes
+// a version byte.
+func (b *Bon) writeHeader(conn net.Conn, r Route, md Metadata) error {
+	version := b.options.handshakeVersion
+	if version == HandshakeLegacy {
+		return b.writeUInt32(conn, uint32(r))
+	}
+
+	if err := b.writeByte(conn, byte(version)); err != nil {
+		return err
+	}
+	if err := b.writeUInt32(conn, uint32(r)); err != nil {
+		return err
+	}
+
+	var payload []byte
+	if len(md) > 0 {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(md); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+	}
+	if err := b.writeUInt32(conn, uint32(len(payload))); err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	_, err := conn.Write(payload)
+	return err
+}
+
+// readHeader reads back whatever writeHeader sent. Since HandshakeLegacy
+// writes no version prefix on the wire, b must be configured with the same
+// HandshakeVersionOption as its peer to read it back correctly.
+func (b *Bon) readHeader(conn net.Conn) (Route, Metadata, error) {
+	if b.options.handshakeVersion == HandshakeLegacy {
+		r, err := b.readUInt32(conn)
+		if err != nil {
+			return 0, nil, err
+		}
+		return Route(r), nil, nil
+	}
+
+	if _, err := b.readByte(conn); err != nil {
+		return 0, nil, err
+	}
+	r, err := b.readUInt32(conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, err := b.readUInt32(conn)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length == 0 {
+		return Route(r), nil, nil
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, nil, err
+	}
+	var md Metadata
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&md); err != nil {
+		return 0, nil, err
+	}
+	return Route(r), md, nil
+}
+
+func (b *Bon) writeByte(conn net.Conn, data byte) error {
+	_, err := conn.Write([]byte{data})
+	return err
+}
+
+func (b *Bon) readByte(conn net.Conn) (byte, error) {
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
 func (b *Bon) writeUInt32(conn net.Conn, data uint32) error {
 	buf := make([]byte, 4)
 	binary.BigEndian.PutUint32(buf, data)
@@ -227,8 +421,7 @@ func (b *Bon) writeUInt32(conn net.Conn, data uint32) error {
 
 func (b *Bon) readUInt32(conn net.Conn) (data uint32, err error) {
 	buf := make([]byte, 4)
-	_, err = conn.Read(buf)
-	if err != nil {
+	if _, err := io.ReadFull(conn, buf); err != nil {
 		return 0, err
 	}
 	s := binary.BigEndian.Uint32(buf)
@@ -1,6 +1,8 @@
 package bon
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"io"
 	"net"
@@ -116,24 +118,32 @@ func (p *provider) Close() error {
 }
 
 func newBons(t *testing.T) (*Bon, *Bon) {
+	return newBonsWithOptions(t)
+}
+
+func newBonsWithOptions(t *testing.T, options ...Option) (*Bon, *Bon) {
 	connC1 := make(chan net.Conn, 0)
 	connC2 := make(chan net.Conn, 0)
 
 	p1 := newProvider(connC1, connC2)
 	p2 := newProvider(connC2, connC1)
 
-	return New(p1), New(p2)
+	return New(p1, options...), New(p2, options...)
 }
 
 var ErrNet = errors.New("net error")
 
 func connect(b *Bon, r Route) (net.Conn, error) {
+	return connectWithMetadata(b, r, nil)
+}
+
+func connectWithMetadata(b *Bon, r Route, md Metadata) (net.Conn, error) {
 	var conn net.Conn
 	var err error
 
 	err = try.Do(func(attempt int) (bool, error) {
 		time.Sleep(time.Millisecond * 2)
-		conn, err = b.Connect(r)
+		conn, err = b.ConnectWithMetadata(r, md)
 		return attempt < 30, err
 	})
 
@@ -145,10 +155,10 @@ func TestHandleNonMatching(t *testing.T) {
 
 	var wg sync.WaitGroup
 	wg.Add(1)
-	b1.HandleNonMatching(func(conn net.Conn) {
+	b1.HandleNonMatching(HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		assert.NotNil(t, conn)
 		wg.Done()
-	})
+	}))
 	go b1.Run()
 
 	conn, err := connect(b2, r)
@@ -169,10 +179,10 @@ func TestHandle(t *testing.T) {
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	b1.Handle(r, func(conn net.Conn) {
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		assert.NotNil(t, conn)
 		wg.Done()
-	})
+	}))
 	go b1.Run()
 
 	var conn net.Conn
@@ -205,9 +215,9 @@ func TestOff(t *testing.T) {
 	r := Route(0)
 	b1, b2 := newBons(t)
 
-	b1.Handle(r, func(conn net.Conn) {
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		assert.Fail(t, "should be removed")
-	})
+	}))
 	go b1.Run()
 
 	b1.Off(r)
@@ -224,9 +234,9 @@ func TestReadWrite(t *testing.T) {
 
 	b1, b2 := newBons(t)
 
-	b1.Handle(r, func(conn net.Conn) {
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		conn.Write(d1)
-	})
+	}))
 	go b1.Run()
 
 	conn, err := connect(b2, r)
@@ -251,18 +261,18 @@ func TestMultipleHandle(t *testing.T) {
 
 	b1, b2 := newBons(t)
 
-	b1.Handle(r1, func(conn net.Conn) {
+	b1.Handle(r1, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		conn.Write(d1)
-	})
-	b1.Handle(r2, func(conn net.Conn) {
+	}))
+	b1.Handle(r2, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		conn.Write(d2)
-	})
-	b2.Handle(r2, func(conn net.Conn) {
+	}))
+	b2.Handle(r2, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		conn.Write(d3)
-	})
-	b2.Handle(r3, func(conn net.Conn) {
+	}))
+	b2.Handle(r3, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
 		conn.Write(d4)
-	})
+	}))
 	go b1.Run()
 	go b2.Run()
 
@@ -302,7 +312,7 @@ func TestMultipleHandle(t *testing.T) {
 func TestCloseAfterRun(t *testing.T) {
 	r := Route(0)
 	b1, b2 := newBons(t)
-	b1.Handle(r, func(net.Conn) {})
+	b1.Handle(r, HandlerFunc(func(context.Context, net.Conn, Metadata) {}))
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -365,3 +375,95 @@ func TestConnectWithoutRemoteRun(t *testing.T) {
 	assert.Equal(t, ErrNet, err)
 	assert.Nil(t, conn)
 }
+
+func TestConnectWithMetadata(t *testing.T) {
+	r := Route(0)
+	b1, b2 := newBons(t)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+		assert.Equal(t, Metadata{"auth-token": "t0ken"}, md)
+		wg.Done()
+	}))
+	go b1.Run()
+
+	conn, err := connectWithMetadata(b2, r, Metadata{"auth-token": "t0ken"})
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	wg.Wait()
+}
+
+func TestUse(t *testing.T) {
+	r := Route(0)
+	b1, b2 := newBons(t)
+
+	var order []string
+	mw := func(tag string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+				order = append(order, tag)
+				next.ServeConn(ctx, conn, md)
+			})
+		}
+	}
+	b1.Use(mw("outer"))
+	b1.Use(mw("inner"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+		order = append(order, "handler")
+		wg.Done()
+	}))
+	go b1.Run()
+
+	conn, err := connect(b2, r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	wg.Wait()
+	assert.Equal(t, []string{"outer", "inner", "handler"}, order)
+}
+
+// TestHandshakeLegacyWireFormat guards against writeHeader regressing into
+// sending a version prefix under HandshakeLegacy: a genuinely old bon
+// deployment expects the bare 4-byte Route baseline Connect always wrote,
+// and nothing else.
+func TestHandshakeLegacyWireFormat(t *testing.T) {
+	r := Route(7)
+	c1, c2 := newConnectedTestConns()
+	b := New(newProvider(nil, nil), HandshakeVersionOption(HandshakeLegacy))
+
+	errC := make(chan error, 1)
+	go func() { errC <- b.writeHeader(c1, r, nil) }()
+
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(c2, buf)
+	assert.Nil(t, err)
+	assert.Nil(t, <-errC)
+	assert.Equal(t, uint32(r), binary.BigEndian.Uint32(buf))
+}
+
+// TestHandshakeLegacyInterop exercises HandshakeLegacy end-to-end: both
+// peers must configure it to interoperate, since it isn't negotiated live.
+func TestHandshakeLegacyInterop(t *testing.T) {
+	r := Route(0)
+	b1, b2 := newBonsWithOptions(t, HandshakeVersionOption(HandshakeLegacy))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+		assert.NotNil(t, conn)
+		assert.Nil(t, md)
+		wg.Done()
+	}))
+	go b1.Run()
+
+	conn, err := connect(b2, r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	wg.Wait()
+}
@@ -0,0 +1,92 @@
+// Package bongrpc lets a single bon connection carry gRPC traffic in both
+// directions. Because a bon session is symmetric, both peers can run a
+// grpc.Server over one of their own Routes while dialing the other peer's
+// Route at the same time, without opening a second socket and without
+// worrying about NAT or TLS in the reverse direction.
+package bongrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ilgooz/bon"
+	"google.golang.org/grpc"
+)
+
+// ErrListenerClosed is returned by a Listener's Accept once Close has been
+// called on it.
+var ErrListenerClosed = errors.New("bongrpc: listener closed")
+
+// Listener adapts b into a net.Listener that hands out connections requested
+// for r, so a grpc.Server can Serve directly on top of a bon session:
+//
+//	grpc.NewServer().Serve(bongrpc.Listener(b, r))
+func Listener(b *bon.Bon, r bon.Route) net.Listener {
+	l := &listener{
+		bon:    b,
+		route:  r,
+		connC:  make(chan net.Conn),
+		closeC: make(chan struct{}),
+	}
+	b.Handle(r, bon.HandlerFunc(l.handleConn))
+	return l
+}
+
+type listener struct {
+	bon   *bon.Bon
+	route bon.Route
+
+	connC  chan net.Conn
+	closeC chan struct{}
+}
+
+func (l *listener) handleConn(ctx context.Context, conn net.Conn, md bon.Metadata) {
+	select {
+	case l.connC <- conn:
+	case <-l.closeC:
+		conn.Close()
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connC:
+		return conn, nil
+	case <-l.closeC:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.bon.Off(l.route)
+	select {
+	case <-l.closeC:
+	default:
+		close(l.closeC)
+	}
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return routeAddr(l.route)
+}
+
+// routeAddr implements net.Addr for a bon.Route, so Listener satisfies
+// net.Listener without needing a real network address.
+type routeAddr bon.Route
+
+func (a routeAddr) Network() string { return "bon" }
+func (a routeAddr) String() string  { return fmt.Sprintf("bon:%d", bon.Route(a)) }
+
+// DialOption returns a grpc.DialOption that dials r over b instead of opening
+// a new network connection, for use with:
+//
+//	grpc.Dial("bon", bongrpc.DialOption(b, r), grpc.WithInsecure())
+func DialOption(b *bon.Bon, r bon.Route) grpc.DialOption {
+	return grpc.WithDialer(func(_ string, _ time.Duration) (net.Conn, error) {
+		return b.Connect(r)
+	})
+}
@@ -0,0 +1,72 @@
+package bongrpc_test
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/ilgooz/bon"
+	"github.com/ilgooz/bon/bongrpc"
+	"github.com/ilgooz/bon/mux"
+	"github.com/ilgooz/bon/mux/yamux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestBidirectionalHealthCheck(t *testing.T) {
+	const r = bon.Route(0)
+
+	server, err := mux.New(yamux.New(nil), mux.ServerOption(":0"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run()
+
+	// wait for server to start
+	time.Sleep(time.Millisecond * 100)
+
+	client, err := mux.New(yamux.New(nil), mux.ClientOption(server.ServerAddr().String()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go client.Run()
+
+	b1 := <-server.Bons
+	go b1.Run()
+
+	b2 := <-client.Bons
+	go b2.Run()
+
+	// both peers run a health server and call the other's, over the same
+	// bon connection.
+	mountHealth(b1, r)
+	mountHealth(b2, r)
+
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, b2, r))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, b1, r))
+}
+
+// mountHealth starts a grpc.Server serving health checks over b's Route r.
+func mountHealth(b *bon.Bon, r bon.Route) {
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(bongrpc.Listener(b, r))
+}
+
+// check dials peer's Route r and returns the reported health status, reaching
+// whichever bon peer has a health server mounted there.
+func check(t *testing.T, peer *bon.Bon, r bon.Route) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	conn, err := grpc.Dial("bon", bongrpc.DialOption(peer, r), grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.Nil(t, err)
+	return resp.Status
+}
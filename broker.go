@@ -0,0 +1,125 @@
+package bon
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// brokerRoute is a reserved Route used by Broker to exchange small hint
+// messages between the two sides of a session. Don't Handle this Route
+// yourself once you start using Broker.
+const brokerRoute Route = 0xFFFFFFFF
+
+// brokerHint is exchanged over brokerRoute to tell the peer that id is ready
+// to be Dial'd, so Dial doesn't race a not-yet-registered Accept.
+type brokerHint struct {
+	id uint32
+}
+
+// Broker lets two sides of a bon session rendezvous on transient, negotiated
+// stream ids rather than on Routes registered up front with Handle. This is
+// useful for flows where a handler needs to open a one-off stream back to
+// whoever called it (e.g. a callback/RPC subchannel) without both peers
+// agreeing on a Route for it beforehand.
+//
+// One side calls Accept(id) to announce it will receive id, the other calls
+// Dial(id) with the same id; Broker handles the handshake so Dial blocks
+// until the matching Accept is in place.
+type Broker struct {
+	bon *Bon
+
+	idSeq uint32
+
+	mu      sync.Mutex
+	readyCs map[uint32]chan struct{}
+}
+
+func newBroker(b *Bon) *Broker {
+	br := &Broker{
+		bon:     b,
+		readyCs: make(map[uint32]chan struct{}),
+	}
+	b.Handle(brokerRoute, HandlerFunc(br.handleHint))
+	return br
+}
+
+// NextID returns an id that hasn't been handed out by this Broker yet, for
+// use with Accept and Dial.
+func (br *Broker) NextID() uint32 {
+	return atomic.AddUint32(&br.idSeq, 1)
+}
+
+// Accept registers id with b and blocks until the peer Dials it, returning
+// the resulting net.Conn. It tells the peer over brokerRoute that id is
+// ready, so callers don't need to sequence their own Accept/Dial calls.
+func (br *Broker) Accept(id uint32) (net.Conn, error) {
+	r := Route(id)
+
+	connC := make(chan net.Conn, 1)
+	br.bon.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+		connC <- conn
+	}))
+	defer br.bon.Off(r)
+
+	if err := br.sendHint(id); err != nil {
+		return nil, err
+	}
+
+	return <-connC, nil
+}
+
+// Dial blocks until the peer signals (via Accept) that it's ready for id,
+// then opens a connection for it.
+func (br *Broker) Dial(id uint32) (net.Conn, error) {
+	<-br.readyChan(id)
+
+	br.mu.Lock()
+	delete(br.readyCs, id)
+	br.mu.Unlock()
+
+	return br.bon.Connect(Route(id))
+}
+
+func (br *Broker) readyChan(id uint32) chan struct{} {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	c, ok := br.readyCs[id]
+	if !ok {
+		c = make(chan struct{}, 1)
+		br.readyCs[id] = c
+	}
+	return c
+}
+
+func (br *Broker) sendHint(id uint32) error {
+	conn, err := br.bon.Connect(brokerRoute)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, id)
+	_, err = conn.Write(buf)
+	return err
+}
+
+func (br *Broker) handleHint(ctx context.Context, conn net.Conn, md Metadata) {
+	defer conn.Close()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	hint := brokerHint{id: binary.BigEndian.Uint32(buf)}
+
+	c := br.readyChan(hint.id)
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package bon
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBrokerCallback shows a handler that, after receiving an initial
+// request, opens a callback stream back to the initiator through the Broker
+// instead of relying on a Route both sides agreed on beforehand.
+func TestBrokerCallback(t *testing.T) {
+	r := Route(1)
+	b1, b2 := newBons(t)
+
+	b1Broker := b1.Broker()
+	b2Broker := b2.Broker()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	b1.Handle(r, HandlerFunc(func(ctx context.Context, conn net.Conn, md Metadata) {
+		defer conn.Close()
+
+		id := b1Broker.NextID()
+		assert.Nil(t, binary.Write(conn, binary.BigEndian, id))
+
+		cb, err := b1Broker.Accept(id)
+		assert.Nil(t, err)
+		defer cb.Close()
+
+		_, err = cb.Write([]byte("callback"))
+		assert.Nil(t, err)
+
+		wg.Done()
+	}))
+	go b1.Run()
+	go b2.Run()
+
+	conn, err := connect(b2, r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	var id uint32
+	assert.Nil(t, binary.Read(conn, binary.BigEndian, &id))
+
+	cb, err := b2Broker.Dial(id)
+	assert.Nil(t, err)
+	assert.NotNil(t, cb)
+
+	data := make([]byte, len("callback"))
+	_, err = cb.Read(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "callback", string(data))
+
+	wg.Wait()
+}
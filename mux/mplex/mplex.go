@@ -0,0 +1,78 @@
+// Package mplex adapts libp2p/go-mplex as a mux.Multiplexer.
+package mplex
+
+import (
+	"context"
+	"net"
+
+	"github.com/ilgooz/bon/mux"
+	multiplex "github.com/libp2p/go-mplex"
+)
+
+// Multiplexer builds libp2p/go-mplex sessions.
+type Multiplexer struct{}
+
+// New returns a Multiplexer.
+func New() *Multiplexer {
+	return &Multiplexer{}
+}
+
+// Server implements mux.Multiplexer.
+func (m *Multiplexer) Server(conn net.Conn) (mux.Muxer, error) {
+	mp, err := multiplex.NewMultiplex(conn, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &session{mp, conn.LocalAddr(), conn.RemoteAddr()}, nil
+}
+
+// Client implements mux.Multiplexer.
+func (m *Multiplexer) Client(conn net.Conn) (mux.Muxer, error) {
+	mp, err := multiplex.NewMultiplex(conn, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &session{mp, conn.LocalAddr(), conn.RemoteAddr()}, nil
+}
+
+// session adapts *multiplex.Multiplex to mux.Muxer; mplex has no separate
+// Open, streams are created with NewStream given a context we don't
+// otherwise need.
+type session struct {
+	*multiplex.Multiplex
+	localAddr, remoteAddr net.Addr
+}
+
+func (s *session) Open() (net.Conn, error) {
+	str, err := s.Multiplex.NewStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &stream{str, s.localAddr, s.remoteAddr}, nil
+}
+
+// Accept implements mux.Muxer. *multiplex.Stream isn't a net.Conn on its
+// own (it has no LocalAddr/RemoteAddr), so it needs the same wrapping Open
+// already does before it can satisfy mux.Muxer.
+func (s *session) Accept() (net.Conn, error) {
+	str, err := s.Multiplex.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &stream{str, s.localAddr, s.remoteAddr}, nil
+}
+
+// stream adapts *multiplex.Stream to net.Conn by giving it the addresses of
+// the underlying session's conn, which every stream it carries shares.
+type stream struct {
+	*multiplex.Stream
+	localAddr, remoteAddr net.Addr
+}
+
+func (s *stream) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+func (s *stream) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
@@ -0,0 +1,330 @@
+// Package mux generalizes Bon's original yamux transporter to work with any
+// stream multiplexing library, not just hashicorp/yamux. Pick whichever
+// multiplexer's flow-control and window semantics fit your workload by
+// passing a different Multiplexer to New; see mux/yamux, mux/smux, and
+// mux/mplex for drop-in implementations.
+package mux
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ilgooz/bon"
+)
+
+// Muxer is a single multiplexed session over one underlying net.Conn. Any
+// stream multiplexing library can back a Mux as long as it produces one of
+// these for both the server and the client side of a connection.
+type Muxer interface {
+	// Open starts a new logical stream over the session.
+	Open() (net.Conn, error)
+
+	// Accept accepts the next logical stream opened by the peer.
+	Accept() (net.Conn, error)
+
+	// Close tears down the session and every stream opened over it.
+	Close() error
+}
+
+// Multiplexer builds a Muxer from the raw net.Conn of a freshly accepted
+// (Server) or dialed (Client) connection. Implementations wrap a specific
+// multiplexing library; see mux/yamux, mux/smux, and mux/mplex.
+type Multiplexer interface {
+	Server(conn net.Conn) (Muxer, error)
+	Client(conn net.Conn) (Muxer, error)
+}
+
+// Mux provides functionalities to use any Multiplexer as a connection layer.
+type Mux struct {
+	// Bons filled everytime there is matching connection.
+	Bons chan *bon.Bon
+
+	// events is written to by ReconnectOption; see SessionEvents.
+	events chan Event
+
+	multiplexer Multiplexer
+	options     *opts
+	dialTimeout time.Duration
+
+	ln net.Listener
+
+	// closing and wg let Close stop a server Mux without racing an
+	// in-flight handleSession's send on Bons, and let it tell a
+	// ReconnectOption client's redial loop to give up for good instead of
+	// just tearing down the current session; see Close.
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type opts struct {
+	address     string
+	isServer    bool
+	dialTimeout time.Duration
+	conn        net.Conn
+	tls         *tls.Config
+	reconnect   *ReconnectPolicy
+}
+
+// New creates a new Mux that multiplexes sessions using m, with given options.
+func New(m Multiplexer, options ...Option) (*Mux, error) {
+	x := &Mux{
+		multiplexer: m,
+		options: &opts{
+			dialTimeout: time.Second * 5,
+		},
+		Bons:    make(chan *bon.Bon, 100),
+		events:  make(chan Event, 10),
+		closing: make(chan struct{}),
+	}
+	for _, o := range options {
+		o(x)
+	}
+	return x, nil
+}
+
+// Option is a Mux option.
+type Option func(*Mux)
+
+// ServerOption makes Mux a TCP server.
+func ServerOption(address string) Option {
+	return func(x *Mux) {
+		x.options.address = address
+		x.options.isServer = true
+	}
+}
+
+// ClientOption makes Mux a TCP client.
+func ClientOption(address string) Option {
+	return func(x *Mux) {
+		x.options.address = address
+	}
+}
+
+// ConnOption receives a conn that will be used as the underlying connection
+// for Mux. You shouldn't use ServerOption or ClientOption with this.
+func ConnOption(conn net.Conn) Option {
+	return func(x *Mux) {
+		x.options.conn = conn
+	}
+}
+
+// DialTimeoutOption is used to timeout while connecting to server.
+func DialTimeoutOption(d time.Duration) Option {
+	return func(x *Mux) {
+		x.options.dialTimeout = d
+	}
+}
+
+// TLSOption transparently upgrades the underlying TCP connection to TLS using
+// c before it's handed off to the Multiplexer. It has no effect when used
+// together with ConnOption; wrap your conn with TLS yourself in that case.
+//
+// If c.ClientAuth requires a client certificate, use PeerCertificates to
+// inspect the chain presented by the remote peer from within a route handler.
+func TLSOption(c *tls.Config) Option {
+	return func(x *Mux) {
+		x.options.tls = c
+	}
+}
+
+// Run starts server or client.
+func (x *Mux) Run() error {
+	if x.options.isServer {
+		if x.options.conn != nil {
+			return x.setupServer(x.options.conn)
+		}
+		return x.handleServer()
+	}
+
+	if x.options.conn != nil {
+		return x.setupClient(x.options.conn)
+	}
+	return x.handleClient()
+}
+
+func (x *Mux) handleServer() error {
+	var err error
+	x.ln, err = net.Listen("tcp", x.options.address)
+	if err != nil {
+		return err
+	}
+	if x.options.tls != nil {
+		x.ln = tls.NewListener(x.ln, x.options.tls)
+	}
+	for {
+		conn, err := x.ln.Accept()
+		if err != nil {
+			return err
+		}
+		err = x.setupServer(conn)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *Mux) handleClient() error {
+	if x.options.reconnect != nil {
+		return x.handleClientWithReconnect()
+	}
+	conn, err := x.dialClient()
+	if err != nil {
+		return err
+	}
+	return x.setupClient(conn)
+}
+
+// dialClient dials x.options.address, upgrading to TLS first if TLSOption
+// was used.
+func (x *Mux) dialClient() (net.Conn, error) {
+	if x.options.tls != nil {
+		return tls.DialWithDialer(&net.Dialer{Timeout: x.dialTimeout}, "tcp", x.options.address, x.options.tls)
+	}
+	return net.DialTimeout("tcp", x.options.address, x.dialTimeout)
+}
+
+func (x *Mux) setupServer(conn net.Conn) error {
+	m, err := x.multiplexer.Server(conn)
+	if err != nil {
+		return err
+	}
+	x.wg.Add(1)
+	go x.handleSession(m, peerCertificatesOf(conn))
+	return nil
+}
+
+func (x *Mux) setupClient(conn net.Conn) error {
+	m, err := x.multiplexer.Client(conn)
+	if err != nil {
+		return err
+	}
+	x.wg.Add(1)
+	go x.handleSession(m, peerCertificatesOf(conn))
+	return nil
+}
+
+func (x *Mux) handleSession(m Muxer, peerCertificates []*x509.Certificate) {
+	defer x.wg.Done()
+	b := x.newBon(m, peerCertificates)
+	select {
+	case x.Bons <- b:
+	case <-x.closing:
+		return
+	}
+	if !x.options.isServer {
+		close(x.Bons)
+	}
+}
+
+// newBon wraps m in a *bon.Bon, recording peerCertificates against it when
+// present. The peerCerts entry is removed again once b is closed, so a
+// long-lived client reconnecting with ReconnectOption doesn't leak one
+// entry per session.
+func (x *Mux) newBon(m Muxer, peerCertificates []*x509.Certificate) *bon.Bon {
+	t := &transporter{muxer: m}
+	b := bon.New(t)
+	if len(peerCertificates) > 0 {
+		peerCertsMu.Lock()
+		peerCerts[b] = peerCertificates
+		peerCertsMu.Unlock()
+		t.onClose = func() {
+			peerCertsMu.Lock()
+			delete(peerCerts, b)
+			peerCertsMu.Unlock()
+		}
+	}
+	return b
+}
+
+// peerCertificatesOf returns the certificate chain presented by conn's remote
+// peer, or nil if conn isn't TLS-secured.
+func peerCertificatesOf(conn net.Conn) []*x509.Certificate {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil
+	}
+	return tlsConn.ConnectionState().PeerCertificates
+}
+
+var (
+	peerCertsMu sync.Mutex
+	peerCerts   = make(map[*bon.Bon][]*x509.Certificate)
+)
+
+// PeerCertificates returns the certificate chain presented by b's remote peer
+// during the TLS handshake, or nil if b's session wasn't established with
+// TLSOption. Route handlers can use this to make authorization decisions
+// based on client certs.
+func PeerCertificates(b *bon.Bon) []*x509.Certificate {
+	peerCertsMu.Lock()
+	defer peerCertsMu.Unlock()
+	return peerCerts[b]
+}
+
+// Close stops accepting new connections for server, and permanently stops a
+// ReconnectOption client's redial loop (closing its current session along
+// the way). It's a no-op for a plain client Mux; close the *bon.Bon you got
+// from Bons instead to tear down its single session.
+func (x *Mux) Close() error {
+	if x.options.isServer {
+		var err error
+		x.closeOnce.Do(func() {
+			close(x.closing)
+			err = x.ln.Close()
+			// wait for any handleSession already past the closing check
+			// to finish delivering its Bon before we close the channel
+			// it sends on, so Close never races a send on a closed Bons.
+			x.wg.Wait()
+			close(x.Bons)
+		})
+		return err
+	}
+	if x.options.reconnect != nil {
+		x.closeOnce.Do(func() {
+			close(x.closing)
+		})
+	}
+	return nil
+}
+
+// ServerAddr returns server's address if Mux started as a server.
+func (x *Mux) ServerAddr() net.Addr {
+	if x.options.isServer {
+		return x.ln.Addr()
+	}
+	return nil
+}
+
+// transporter adapts a Muxer to bon.Transporter by ignoring the requested
+// Route on Open; Muxer sessions don't distinguish streams by Route, bon's own
+// handshake over the resulting net.Conn takes care of that.
+type transporter struct {
+	muxer Muxer
+
+	// onClose, if set, runs after the underlying session is closed; used
+	// by newBon to clean up peerCerts.
+	onClose func()
+}
+
+func (t *transporter) Accept() (net.Conn, error) {
+	return t.muxer.Accept()
+}
+
+func (t *transporter) Open(bon.Route) (net.Conn, error) {
+	return t.muxer.Open()
+}
+
+func (t *transporter) Close() error {
+	err := t.muxer.Close()
+	if t.onClose != nil {
+		t.onClose()
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package mux_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilgooz/bon"
+	"github.com/ilgooz/bon/mux"
+	"github.com/ilgooz/bon/mux/yamux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientServer(t *testing.T) {
+	var wg sync.WaitGroup
+	r := bon.Route(0)
+
+	server, err := mux.New(yamux.New(nil), mux.ServerOption(":0"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run()
+
+	wg.Add(1)
+	go func() {
+		b := <-server.Bons
+		assert.NotNil(t, b)
+
+		wg.Add(1)
+		b.Handle(r, bon.HandlerFunc(func(ctx context.Context, conn net.Conn, md bon.Metadata) {
+			assert.NotNil(t, conn)
+			wg.Done()
+		}))
+		go b.Run()
+
+		assert.Nil(t, <-server.Bons)
+		wg.Done()
+	}()
+
+	// wait for server to start
+	time.Sleep(time.Millisecond * 100)
+
+	client, err := mux.New(yamux.New(nil), mux.ClientOption(server.ServerAddr().String()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go client.Run()
+
+	b := <-client.Bons
+	assert.NotNil(t, b)
+
+	conn, err := b.Connect(r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	assert.Nil(t, <-client.Bons)
+	server.Close()
+
+	wg.Wait()
+}
+
+func TestTLSClientServer(t *testing.T) {
+	r := bon.Route(0)
+	cert := selfSignedCert(t)
+
+	serverConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}
+	clientConf := &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		InsecureSkipVerify: true,
+	}
+
+	server, err := mux.New(yamux.New(nil), mux.ServerOption(":0"), mux.TLSOption(serverConf))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run()
+
+	// wait for server to start
+	time.Sleep(time.Millisecond * 100)
+
+	client, err := mux.New(yamux.New(nil), mux.ClientOption(server.ServerAddr().String()), mux.TLSOption(clientConf))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go client.Run()
+
+	serverBon := <-server.Bons
+	assert.NotNil(t, serverBon)
+	assert.NotEmpty(t, mux.PeerCertificates(serverBon))
+
+	serverBon.Handle(r, bon.HandlerFunc(func(context.Context, net.Conn, bon.Metadata) {}))
+	go serverBon.Run()
+
+	clientBon := <-client.Bons
+	assert.NotNil(t, clientBon)
+	assert.NotEmpty(t, mux.PeerCertificates(clientBon))
+
+	conn, err := clientBon.Connect(r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	server.Close()
+}
+
+// selfSignedCert generates a throwaway self-signed certificate for TLS tests.
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bon-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
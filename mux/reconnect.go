@@ -0,0 +1,177 @@
+package mux
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ilgooz/bon"
+)
+
+// Event describes a change in a client Mux's session, emitted on
+// SessionEvents when ReconnectOption is used.
+type Event int
+
+const (
+	// Connected is emitted once a session (and a fresh *bon.Bon on Bons) is
+	// established, including after every successful reconnect.
+	Connected Event = iota
+
+	// Disconnected is emitted when the active session dies.
+	Disconnected
+
+	// Reconnecting is emitted right before a new dial attempt following a
+	// Disconnected.
+	Reconnecting
+)
+
+// ReconnectPolicy configures the backoff ReconnectOption uses between dial
+// attempts after a client's session dies, mirroring how frp and
+// consul-style provider clients reconnect.
+type ReconnectPolicy struct {
+	// MinBackoff is the delay before the first reconnect attempt.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the exponential backoff between later attempts.
+	MaxBackoff time.Duration
+
+	// Jitter randomizes each backoff by up to this fraction (0..1) of its
+	// value, so peers reconnecting at the same time don't all redial in
+	// lockstep.
+	Jitter float64
+
+	// MaxAttempts caps how many consecutive dial failures Mux tolerates
+	// before Run gives up and returns an error. Zero means unlimited.
+	MaxAttempts int
+}
+
+// ReconnectOption makes a client Mux redial with policy's backoff whenever
+// its session dies, instead of closing Bons after the first one. Each
+// successful (re)dial delivers a fresh *bon.Bon on Bons, and the session it
+// replaces is closed; re-apply your Handle registrations to the new Bon on
+// SessionEvents' Connected instead of racing the next receive on Bons.
+//
+// ReconnectOption has no effect on a server Mux.
+func ReconnectOption(policy ReconnectPolicy) Option {
+	return func(x *Mux) {
+		x.options.reconnect = &policy
+	}
+}
+
+// SessionEvents returns a channel emitting Connected, Disconnected, and
+// Reconnecting as a ReconnectOption client's session comes up and goes down.
+// It's only written to when ReconnectOption is used.
+func (x *Mux) SessionEvents() <-chan Event {
+	return x.events
+}
+
+// sessionWaiter is implemented by Muxer sessions that can report when they
+// die, e.g. hashicorp/yamux's Session.CloseChan. ReconnectOption relies on it
+// to notice a dead session and redial; a Muxer that doesn't implement it is
+// still usable, but Mux has no way to tell the session died, so it sits on
+// that session until Close is called instead of busy-looping redials
+// against it.
+type sessionWaiter interface {
+	CloseChan() <-chan struct{}
+}
+
+func sessionDone(m Muxer) <-chan struct{} {
+	if w, ok := m.(sessionWaiter); ok {
+		return w.CloseChan()
+	}
+	return nil
+}
+
+// handleClientWithReconnect is handleClient's behavior under ReconnectOption:
+// it keeps redialing with policy's backoff for as long as the session (or
+// the dial itself) keeps failing, delivering a fresh Bon on Bons after every
+// successful dial and emitting Event's along the way, until Close stops it
+// for good.
+func (x *Mux) handleClientWithReconnect() error {
+	policy := *x.options.reconnect
+	backoff := policy.MinBackoff
+	attempts := 0
+	var prevBon *bon.Bon
+
+	stop := func() error {
+		if prevBon != nil {
+			prevBon.Close()
+		}
+		close(x.Bons)
+		return nil
+	}
+
+	for {
+		select {
+		case <-x.closing:
+			return stop()
+		default:
+		}
+
+		conn, err := x.dialClient()
+		if err == nil {
+			var m Muxer
+			m, err = x.multiplexer.Client(conn)
+			if err != nil {
+				conn.Close()
+			}
+			if err == nil {
+				attempts = 0
+				backoff = policy.MinBackoff
+
+				if prevBon != nil {
+					prevBon.Close()
+				}
+
+				b := x.newBon(m, peerCertificatesOf(conn))
+				prevBon = b
+				x.Bons <- b
+				x.emit(Connected)
+
+				select {
+				case <-sessionDone(m):
+				case <-x.closing:
+					return stop()
+				}
+				x.emit(Disconnected)
+			}
+		}
+
+		if err != nil {
+			attempts++
+			if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+				close(x.Bons)
+				return err
+			}
+		}
+
+		x.emit(Reconnecting)
+		select {
+		case <-time.After(jitter(backoff, policy.Jitter)):
+		case <-x.closing:
+			return stop()
+		}
+		if backoff < policy.MaxBackoff {
+			backoff *= 2
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
+
+// emit sends e on x.events without blocking Run if nobody's listening.
+func (x *Mux) emit(e Event) {
+	select {
+	case x.events <- e:
+	default:
+	}
+}
+
+// jitter randomizes d by up to frac (0..1) of its value.
+func jitter(d time.Duration, frac float64) time.Duration {
+	if frac <= 0 {
+		return d
+	}
+	delta := time.Duration(float64(d) * frac * rand.Float64())
+	return d + delta
+}
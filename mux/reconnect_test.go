@@ -0,0 +1,95 @@
+package mux_test
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/ilgooz/bon/mux"
+	"github.com/ilgooz/bon/mux/yamux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnect(t *testing.T) {
+	server, err := mux.New(yamux.New(nil), mux.ServerOption(":0"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run()
+
+	// wait for server to start
+	time.Sleep(time.Millisecond * 100)
+
+	client, err := mux.New(yamux.New(nil), mux.ClientOption(server.ServerAddr().String()), mux.ReconnectOption(mux.ReconnectPolicy{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond * 10,
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go client.Run()
+
+	assert.Equal(t, mux.Connected, <-client.SessionEvents())
+
+	serverBon := <-server.Bons
+	assert.NotNil(t, serverBon)
+
+	firstBon := <-client.Bons
+	assert.NotNil(t, firstBon)
+
+	// killing the server's side of the session should make the client
+	// notice, reconnect, and deliver a fresh Bon.
+	assert.Nil(t, serverBon.Close())
+
+	assert.Equal(t, mux.Disconnected, <-client.SessionEvents())
+	assert.Equal(t, mux.Reconnecting, <-client.SessionEvents())
+	assert.Equal(t, mux.Connected, <-client.SessionEvents())
+
+	secondBon := <-client.Bons
+	assert.NotNil(t, secondBon)
+	assert.True(t, firstBon != secondBon)
+
+	server.Close()
+}
+
+// TestReconnectClose verifies Close gives a ReconnectOption client a way to
+// stop redialing for good, instead of each session death just triggering
+// another reconnect forever.
+func TestReconnectClose(t *testing.T) {
+	server, err := mux.New(yamux.New(nil), mux.ServerOption(":0"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run()
+
+	// wait for server to start
+	time.Sleep(time.Millisecond * 100)
+
+	client, err := mux.New(yamux.New(nil), mux.ClientOption(server.ServerAddr().String()), mux.ReconnectOption(mux.ReconnectPolicy{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond * 10,
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	runErrC := make(chan error, 1)
+	go func() { runErrC <- client.Run() }()
+
+	assert.Equal(t, mux.Connected, <-client.SessionEvents())
+	assert.NotNil(t, <-client.Bons)
+
+	assert.Nil(t, client.Close())
+
+	// Run should return and Bons should close instead of a fresh Bon ever
+	// showing up again.
+	select {
+	case err := <-runErrC:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("client.Run did not return after Close")
+	}
+	_, ok := <-client.Bons
+	assert.False(t, ok)
+
+	server.Close()
+}
@@ -0,0 +1,52 @@
+// Package smux adapts xtaci/smux as a mux.Multiplexer.
+package smux
+
+import (
+	"net"
+
+	"github.com/ilgooz/bon/mux"
+	"github.com/xtaci/smux"
+)
+
+// Multiplexer builds xtaci/smux sessions.
+type Multiplexer struct {
+	config *smux.Config
+}
+
+// New returns a Multiplexer that configures every session it builds with c.
+// c may be nil to use smux's defaults.
+func New(c *smux.Config) *Multiplexer {
+	return &Multiplexer{config: c}
+}
+
+// Server implements mux.Multiplexer.
+func (m *Multiplexer) Server(conn net.Conn) (mux.Muxer, error) {
+	s, err := smux.Server(conn, m.config)
+	if err != nil {
+		return nil, err
+	}
+	return &session{s}, nil
+}
+
+// Client implements mux.Multiplexer.
+func (m *Multiplexer) Client(conn net.Conn) (mux.Muxer, error) {
+	s, err := smux.Client(conn, m.config)
+	if err != nil {
+		return nil, err
+	}
+	return &session{s}, nil
+}
+
+// session adapts *smux.Session to mux.Muxer; smux names its stream methods
+// differently than hashicorp/yamux so they can't be embedded as-is.
+type session struct {
+	*smux.Session
+}
+
+func (s *session) Open() (net.Conn, error) {
+	return s.Session.OpenStream()
+}
+
+func (s *session) Accept() (net.Conn, error) {
+	return s.Session.AcceptStream()
+}
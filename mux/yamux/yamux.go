@@ -0,0 +1,30 @@
+// Package yamux adapts hashicorp/yamux as a mux.Multiplexer.
+package yamux
+
+import (
+	"net"
+
+	"github.com/hashicorp/yamux"
+	"github.com/ilgooz/bon/mux"
+)
+
+// Multiplexer builds hashicorp/yamux sessions.
+type Multiplexer struct {
+	config *yamux.Config
+}
+
+// New returns a Multiplexer that configures every session it builds with c.
+// c may be nil to use yamux's defaults.
+func New(c *yamux.Config) *Multiplexer {
+	return &Multiplexer{config: c}
+}
+
+// Server implements mux.Multiplexer.
+func (m *Multiplexer) Server(conn net.Conn) (mux.Muxer, error) {
+	return yamux.Server(conn, m.config)
+}
+
+// Client implements mux.Multiplexer.
+func (m *Multiplexer) Client(conn net.Conn) (mux.Muxer, error) {
+	return yamux.Client(conn, m.config)
+}
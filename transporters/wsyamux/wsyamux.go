@@ -0,0 +1,188 @@
+// Package wsyamux is Bon Transporter based on hashicorp/yamux running over a
+// WebSocket connection instead of a raw TCP one, so a bon session can traverse
+// HTTP proxies and TLS terminators where raw TCP yamux won't work.
+package wsyamux
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/ilgooz/bon"
+)
+
+// errClosed is returned by setupYamuxServer once Close has run, so ServeHTTP
+// closes the raw conn instead of proceeding with a session that would race
+// Close's close(Bons).
+var errClosed = errors.New("wsyamux: closed")
+
+// Yamux provides functionalities to use hashicorp/yamux over a WebSocket
+// connection as a connection layer.
+type Yamux struct {
+	// Bons filled everytime there is matching connection.
+	Bons chan *bon.Bon
+
+	options *opts
+	doneC   chan struct{}
+
+	// mu guards closed, and is held around every handleSession registration
+	// and around Close's read of it. Unlike mux.Mux, Yamux doesn't own the
+	// *http.Server it's mounted on, so Close can't stop new requests from
+	// reaching ServeHTTP the way closing a net.Listener does; mu is what
+	// keeps a late-arriving setupYamuxServer from registering with wg after
+	// Close has already waited on it and closed Bons.
+	mu     sync.Mutex
+	closed bool
+
+	// wg tracks in-flight handleSession calls so Close can wait for them to
+	// finish delivering their Bon before closing it; see Close.
+	wg sync.WaitGroup
+}
+
+type opts struct {
+	isServer bool
+	url      string
+	header   http.Header
+	upgrader websocket.Upgrader
+	dialer   websocket.Dialer
+	yamux    *yamux.Config
+}
+
+// New creates a new Yamux with given options.
+func New(options ...Option) (*Yamux, error) {
+	y := &Yamux{
+		Bons:  make(chan *bon.Bon, 100),
+		doneC: make(chan struct{}),
+		options: &opts{
+			dialer: websocket.Dialer{
+				HandshakeTimeout: time.Second * 5,
+			},
+		},
+	}
+	for _, o := range options {
+		o(y)
+	}
+	return y, nil
+}
+
+// Option is a Yamux option.
+type Option func(*Yamux)
+
+// ServerOption makes Yamux a WebSocket server. Yamux implements http.Handler,
+// so mount it at whatever path suits your existing *http.Server, e.g.
+// mux.Handle("/bon", y).
+func ServerOption() Option {
+	return func(y *Yamux) {
+		y.options.isServer = true
+	}
+}
+
+// ClientOption makes Yamux a WebSocket client dialing url (which should use the
+// ws:// or wss:// scheme), sending header on the handshake request.
+func ClientOption(url string, header http.Header) Option {
+	return func(y *Yamux) {
+		y.options.url = url
+		y.options.header = header
+	}
+}
+
+// YamuxConfigOption passed directly to hashicorp/yamux.
+func YamuxConfigOption(c *yamux.Config) Option {
+	return func(y *Yamux) {
+		y.options.yamux = c
+	}
+}
+
+// Run starts the client dial. Server sessions are instead established as
+// requests arrive at ServeHTTP; for a server Run simply blocks until Close
+// is called.
+func (y *Yamux) Run() error {
+	if y.options.isServer {
+		<-y.doneC
+		return nil
+	}
+	return y.handleClient()
+}
+
+// ServeHTTP upgrades the HTTP request to a WebSocket connection and starts a
+// yamux session over it, delivering the resulting *bon.Bon on Bons.
+func (y *Yamux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := y.options.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	if err := y.setupYamuxServer(&wsConn{Conn: conn}); err != nil {
+		conn.Close()
+	}
+}
+
+func (y *Yamux) handleClient() error {
+	conn, _, err := y.options.dialer.Dial(y.options.url, y.options.header)
+	if err != nil {
+		return err
+	}
+	return y.setupYamuxClient(&wsConn{Conn: conn})
+}
+
+func (y *Yamux) setupYamuxServer(conn net.Conn) error {
+	y.mu.Lock()
+	if y.closed {
+		y.mu.Unlock()
+		return errClosed
+	}
+	y.wg.Add(1)
+	y.mu.Unlock()
+
+	s, err := yamux.Server(conn, y.options.yamux)
+	if err != nil {
+		y.wg.Done()
+		return err
+	}
+	y.handleSession(s)
+	return nil
+}
+
+func (y *Yamux) setupYamuxClient(conn net.Conn) error {
+	s, err := yamux.Client(conn, y.options.yamux)
+	if err != nil {
+		return err
+	}
+	y.handleSession(s)
+	return nil
+}
+
+func (y *Yamux) handleSession(s *yamux.Session) {
+	srv := newService(s)
+	b := bon.New(srv)
+	if y.options.isServer {
+		defer y.wg.Done()
+		y.Bons <- b
+		return
+	}
+	y.Bons <- b
+	close(y.Bons)
+}
+
+// Close stops accepting new connections for server. It has no effect on a
+// client; close the *bon.Bon received on Bons instead.
+func (y *Yamux) Close() error {
+	if !y.options.isServer {
+		return nil
+	}
+	y.mu.Lock()
+	y.closed = true
+	y.mu.Unlock()
+	close(y.doneC)
+	// wait for any handleSession whose setupYamuxServer registered with wg
+	// before the mu.Lock above ran to finish delivering its Bon before we
+	// close the channel it sends on, so Close never races a send on a
+	// closed Bons. Once closed is true, no further registration can happen,
+	// so this Wait is guaranteed to eventually see wg's count drop to zero.
+	y.wg.Wait()
+	close(y.Bons)
+	return nil
+}
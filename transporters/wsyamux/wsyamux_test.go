@@ -0,0 +1,98 @@
+package wsyamux
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ilgooz/bon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientServer(t *testing.T) {
+	var wg sync.WaitGroup
+	r := bon.Route(0)
+
+	server, err := New(ServerOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Run()
+
+	mux := http.NewServeMux()
+	mux.Handle("/bon", server)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	wg.Add(1)
+	go func() {
+		serverBon := <-server.Bons
+		assert.NotNil(t, serverBon)
+
+		wg.Add(1)
+		serverBon.Handle(r, bon.HandlerFunc(func(ctx context.Context, conn net.Conn, md bon.Metadata) {
+			assert.NotNil(t, conn)
+			wg.Done()
+		}))
+		go serverBon.Run()
+
+		assert.Nil(t, <-server.Bons)
+		wg.Done()
+	}()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/bon"
+	client, err := New(ClientOption(url, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go client.Run()
+
+	clientBon := <-client.Bons
+	assert.NotNil(t, clientBon)
+
+	conn, err := clientBon.Connect(r)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+
+	assert.Nil(t, <-client.Bons)
+	server.Close()
+
+	wg.Wait()
+}
+
+// TestCloseDuringHandshake guards against handleSession's send on Bons
+// racing a concurrent Close: a WebSocket upgrade in flight when Close runs
+// must never panic with "send on closed channel".
+func TestCloseDuringHandshake(t *testing.T) {
+	server, err := New(ServerOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Run()
+
+	mux := http.NewServeMux()
+	mux.Handle("/bon", server)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/bon"
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dialer := websocket.Dialer{}
+		conn, _, err := dialer.Dial(url, nil)
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	server.Close()
+	wg.Wait()
+}